@@ -100,6 +100,16 @@ type Config struct {
 	// Shape describes the shape of a cluster.
 	Shape Shape
 
+	// AutoFetch, if true, tells Fixture implementations that support it
+	// (e.g. integration.ControlPlane) to download the kube-apiserver,
+	// kubectl, and etcd binaries they need instead of requiring them to
+	// already be staged on disk.
+	AutoFetch bool
+
+	// KubernetesVersion is the Kubernetes version to resolve binaries
+	// for when AutoFetch is set. Ignored otherwise.
+	KubernetesVersion string
+
 	base.ClusterConfiguration
 	lightweightMasterConfiguration
 }
@@ -0,0 +1,113 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/testing_frameworks/cluster"
+	"sigs.k8s.io/testing_frameworks/integration/internal"
+)
+
+// APIServer knows how to run a kube-apiserver.
+type APIServer struct {
+	// URL is the address the APIServer should listen on for client
+	// connections.
+	//
+	// If this is not specified, we default to a random free port on
+	// localhost.
+	URL *url.URL
+
+	// Path is the path to the kube-apiserver binary.
+	//
+	// If this is left as the empty string, we will attempt to locate a
+	// binary, by checking for the TEST_ASSET_KUBE_APISERVER environment
+	// variable, and the default test assets directory.
+	Path string
+
+	// EtcdURL is the address of the etcd this APIServer should use as its
+	// backing store, passed as --etcd-servers.
+	EtcdURL *url.URL
+
+	// EtcdTLSConfig, if set, is the TLS configuration of the etcd pointed
+	// to by EtcdURL. Its CA certificate is trusted via --etcd-cafile, and
+	// its client certificate/key are presented via --etcd-certfile and
+	// --etcd-keyfile.
+	EtcdTLSConfig *TLSConfig
+
+	// ClusterConfig is the kubeadm-compatible configuration for clusters,
+	// which is partially supported by this framework.
+	ClusterConfig cluster.Config
+
+	// StartTimeout, StopTimeout specify the time the APIServer is allowed
+	// to take when starting and stopping before an error is emitted.
+	//
+	// If not specified, these default to 20 seconds.
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+
+	// Out, Err specify where APIServer should write its StdOut, StdErr to.
+	//
+	// If not specified, the output will be discarded.
+	Out io.Writer
+	Err io.Writer
+
+	processState *internal.ProcessState
+}
+
+// Start starts the apiserver, waits for it to come up, and returns an
+// error, if one occoured.
+func (s *APIServer) Start() error {
+	var err error
+
+	s.processState = &internal.ProcessState{}
+
+	s.processState.DefaultedProcessInput, err = internal.DoDefaulting(
+		"kube-apiserver",
+		s.URL,
+		"",
+		s.Path,
+		s.StartTimeout,
+		s.StopTimeout,
+	)
+	if err != nil {
+		return err
+	}
+
+	s.URL = &s.processState.URL
+	s.Path = s.processState.Path
+	s.StartTimeout = s.processState.StartTimeout
+	s.StopTimeout = s.processState.StopTimeout
+
+	args := flattenArgs(s.ClusterConfig.API.ExtraArgs)
+
+	etcdArgs, err := s.etcdArgs()
+	if err != nil {
+		return fmt.Errorf("unable to set up etcd TLS client files: %v", err)
+	}
+	args = append(args, etcdArgs...)
+
+	s.processState.Args = append(internal.DoAPIServerArgDefaulting(args),
+		"--etcd-servers="+s.EtcdURL.String())
+
+	return s.processState.Start(s.Out, s.Err)
+}
+
+// Stop stops this process gracefully, waits for its termination, and
+// cleans up the DataDir if necessary.
+func (s *APIServer) Stop() error {
+	return s.processState.Stop()
+}
+
+// etcdArgs returns the --etcd-cafile/--etcd-certfile/--etcd-keyfile flags
+// needed to dial EtcdURL, if it is secured with TLS.
+func (s *APIServer) etcdArgs() ([]string, error) {
+	if s.EtcdTLSConfig == nil {
+		return nil, nil
+	}
+
+	certsDir := filepath.Join(s.processState.Dir, "etcd-certs")
+	return writeEtcdClientTLSFiles(certsDir, s.EtcdTLSConfig)
+}
@@ -0,0 +1,223 @@
+// Package binaries knows how to fetch the kube-apiserver, kubectl, and etcd
+// binaries that the integration package needs, so that callers no longer
+// have to stage them by hand via install-etcd.sh or KUBEBUILDER_ASSETS.
+package binaries
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// mirrorURL is the base of the published control-plane binaries mirror.
+// The full download URL is mirrorURL/kubebuilder-tools-<version>-<os>-<arch>.tar.gz,
+// with a .sha256 sibling holding the expected checksum.
+const mirrorURL = "https://storage.googleapis.com/kubebuilder-tools"
+
+// Assets are the paths to the binaries Fetch resolved or downloaded.
+type Assets struct {
+	KubeAPIServerPath string
+	KubectlPath       string
+	EtcdPath          string
+}
+
+// Options customizes Fetch.
+type Options struct {
+	// OS, Arch default to runtime.GOOS, runtime.GOARCH.
+	OS, Arch string
+
+	// CacheDir overrides where downloaded tarballs are cached. Defaults
+	// to $XDG_CACHE_HOME/kubebuilder-envtest (or the OS equivalent).
+	CacheDir string
+}
+
+// Fetch returns the kube-apiserver, kubectl, and etcd binaries matching
+// version, downloading and caching them under CacheDir if they are not
+// already present. It is safe to call concurrently for the same version:
+// a cache hit is just a directory-exists check, and a cache miss extracts
+// into a temporary directory and renames it into place atomically, so
+// concurrent callers never observe a partially-written versionDir.
+func Fetch(ctx context.Context, version string, opts Options) (Assets, error) {
+	if opts.OS == "" {
+		opts.OS = runtime.GOOS
+	}
+	if opts.Arch == "" {
+		opts.Arch = runtime.GOARCH
+	}
+
+	dir, err := cacheDir(opts)
+	if err != nil {
+		return Assets{}, fmt.Errorf("unable to determine cache directory: %v", err)
+	}
+
+	versionDir := filepath.Join(dir, "k8s", fmt.Sprintf("%s-%s-%s", version, opts.OS, opts.Arch))
+	assets := Assets{
+		KubeAPIServerPath: filepath.Join(versionDir, "kube-apiserver"),
+		KubectlPath:       filepath.Join(versionDir, "kubectl"),
+		EtcdPath:          filepath.Join(versionDir, "etcd"),
+	}
+
+	if haveAllAssets(assets) {
+		return assets, nil
+	}
+
+	parentDir := filepath.Dir(versionDir)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return Assets{}, fmt.Errorf("unable to create %s: %v", parentDir, err)
+	}
+
+	tarballName := fmt.Sprintf("kubebuilder-tools-%s-%s-%s.tar.gz", version, opts.OS, opts.Arch)
+	tarballURL := mirrorURL + "/" + tarballName
+
+	tarball, err := download(ctx, tarballURL)
+	if err != nil {
+		return Assets{}, fmt.Errorf("unable to download %s: %v", tarballURL, err)
+	}
+
+	if err := verifyChecksum(ctx, tarballURL, tarball); err != nil {
+		return Assets{}, fmt.Errorf("checksum mismatch for %s: %v", tarballURL, err)
+	}
+
+	// Extract into a private scratch directory first, and only rename it
+	// into versionDir once it's fully populated. Two concurrent callers
+	// racing on the same cache miss each extract into their own scratch
+	// directory, so neither ever sees the other's half-written files; the
+	// loser's rename fails because the winner's versionDir already exists,
+	// and its now-redundant scratch directory is discarded.
+	scratchDir, err := os.MkdirTemp(parentDir, filepath.Base(versionDir)+".tmp-")
+	if err != nil {
+		return Assets{}, fmt.Errorf("unable to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	if err := extractTarGz(tarball, scratchDir); err != nil {
+		return Assets{}, fmt.Errorf("unable to extract %s: %v", tarballName, err)
+	}
+
+	if err := os.Rename(scratchDir, versionDir); err != nil {
+		if !haveAllAssets(assets) {
+			return Assets{}, fmt.Errorf("unable to install %s into %s: %v", tarballName, versionDir, err)
+		}
+		// Another caller won the race and populated versionDir first; its
+		// result is just as valid as ours.
+	}
+
+	if !haveAllAssets(assets) {
+		return Assets{}, fmt.Errorf("%s did not contain kube-apiserver, kubectl, and etcd", tarballName)
+	}
+
+	return assets, nil
+}
+
+func haveAllAssets(a Assets) bool {
+	for _, p := range []string{a.KubeAPIServerPath, a.KubectlPath, a.EtcdPath} {
+		if _, err := os.Stat(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func cacheDir(opts Options) (string, error) {
+	if opts.CacheDir != "" {
+		return opts.CacheDir, nil
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "kubebuilder-envtest"), nil
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func verifyChecksum(ctx context.Context, tarballURL string, tarball []byte) error {
+	expected, err := download(ctx, tarballURL+".sha256")
+	if err != nil {
+		return fmt.Errorf("unable to fetch checksum: %v", err)
+	}
+
+	sum := sha256.Sum256(tarball)
+	actual := hex.EncodeToString(sum[:])
+
+	want := string(expected)
+	if len(want) >= 64 {
+		want = want[:64]
+	}
+
+	if actual != want {
+		return fmt.Errorf("got %s, want %s", actual, want)
+	}
+	return nil
+}
+
+func extractTarGz(data []byte, destDir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// The tarball nests everything under kubebuilder/bin/; we only
+		// care about the three binaries themselves.
+		name := filepath.Base(hdr.Name)
+		switch name {
+		case "kube-apiserver", "kubectl", "etcd":
+		default:
+			continue
+		}
+
+		out, err := os.OpenFile(filepath.Join(destDir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package binaries
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestVerifyChecksumMismatch(t *testing.T) {
+	tarball := []byte("not actually a tarball")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0000000000000000000000000000000000000000000000000000000000000000"))
+	}))
+	defer srv.Close()
+
+	err := verifyChecksum(context.Background(), srv.URL+"/kubebuilder-tools.tar.gz", tarball)
+	if err == nil {
+		t.Fatal("verifyChecksum() with a mismatching checksum: got nil error, want an error")
+	}
+}
+
+func TestVerifyChecksumMatch(t *testing.T) {
+	tarball := []byte("a perfectly good tarball")
+	sum := sha256.Sum256(tarball)
+	want := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(want))
+	}))
+	defer srv.Close()
+
+	if err := verifyChecksum(context.Background(), srv.URL+"/kubebuilder-tools.tar.gz", tarball); err != nil {
+		t.Errorf("verifyChecksum() with a matching checksum: unexpected error: %v", err)
+	}
+}
+
+func TestHaveAllAssets(t *testing.T) {
+	dir := t.TempDir()
+	assets := Assets{
+		KubeAPIServerPath: filepath.Join(dir, "kube-apiserver"),
+		KubectlPath:       filepath.Join(dir, "kubectl"),
+		EtcdPath:          filepath.Join(dir, "etcd"),
+	}
+
+	if haveAllAssets(assets) {
+		t.Fatal("haveAllAssets() on an empty directory: got true, want false")
+	}
+
+	for _, p := range []string{assets.KubeAPIServerPath, assets.KubectlPath, assets.EtcdPath} {
+		if err := os.WriteFile(p, []byte("x"), 0755); err != nil {
+			t.Fatalf("unable to write %s: %v", p, err)
+		}
+	}
+
+	if !haveAllAssets(assets) {
+		t.Error("haveAllAssets() with all three binaries present: got false, want true")
+	}
+}
+
+func TestFetchCacheHitSkipsNetwork(t *testing.T) {
+	cacheDir := t.TempDir()
+	versionDir := filepath.Join(cacheDir, "k8s", "1.2.3-"+runtime.GOOS+"-"+runtime.GOARCH)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		t.Fatalf("unable to set up fake cache: %v", err)
+	}
+	for _, name := range []string{"kube-apiserver", "kubectl", "etcd"} {
+		if err := os.WriteFile(filepath.Join(versionDir, name), []byte("x"), 0755); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+	}
+
+	assets, err := Fetch(context.Background(), "1.2.3", Options{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatalf("Fetch() on a warm cache: unexpected error: %v", err)
+	}
+	if assets.EtcdPath != filepath.Join(versionDir, "etcd") {
+		t.Errorf("Fetch().EtcdPath = %q, want %q", assets.EtcdPath, filepath.Join(versionDir, "etcd"))
+	}
+}
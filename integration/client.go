@@ -0,0 +1,94 @@
+package integration
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Client returns a cached, lazily-constructed etcd v3 client pointed at
+// this Etcd's URL, honoring TLSConfig if set.
+//
+// If the underlying etcd process has been restarted since the client was
+// built (for example via StopMember/StartMember on an EtcdCluster, or a
+// failpoint-triggered crash), Client re-establishes the connection rather
+// than handing back a client dialed to a process that no longer exists.
+// Call ResetClient to force reconnection explicitly.
+func (e *Etcd) Client(ctx context.Context) (*clientv3.Client, error) {
+	if e.client != nil && e.clientGeneration == e.generation {
+		return e.client, nil
+	}
+
+	return e.dialClient(ctx)
+}
+
+// ResetClient closes and discards the cached client returned by Client, so
+// that the next call to Client dials a fresh connection.
+func (e *Etcd) ResetClient() {
+	if e.client != nil {
+		e.client.Close()
+		e.client = nil
+	}
+}
+
+func (e *Etcd) dialClient(ctx context.Context) (*clientv3.Client, error) {
+	e.ResetClient()
+
+	cfg := clientv3.Config{
+		Endpoints: []string{e.URL.String()},
+		Context:   ctx,
+	}
+
+	if e.TLSConfig != nil {
+		tlsConfig, err := e.TLSConfig.clientTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to build TLS config for etcd client: %v", err)
+		}
+		cfg.TLS = tlsConfig
+	}
+
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial etcd at %s: %v", e.URL, err)
+	}
+
+	e.client = client
+	e.clientGeneration = e.generation
+	return client, nil
+}
+
+// Client returns a cached, lazily-constructed etcd v3 client pointed at
+// this ControlPlane's etcd, re-dialing if etcd has been restarted since
+// the client was built. See (*Etcd).Client for details.
+func (c *ControlPlane) Client(ctx context.Context) (*clientv3.Client, error) {
+	return c.Etcd.Client(ctx)
+}
+
+// ResetClient closes and discards the cached client returned by Client, so
+// that the next call to Client dials a fresh connection.
+func (c *ControlPlane) ResetClient() {
+	c.Etcd.ResetClient()
+}
+
+// clientTLSConfig builds a *tls.Config from t's CA and client cert/key, so
+// that a client dialing etcd presents the right certificate and trusts the
+// fixture's CA.
+func (t *TLSConfig) clientTLSConfig() (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(t.ClientCert, t.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client cert/key: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(t.CACert) {
+		return nil, fmt.Errorf("unable to parse CA certificate")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
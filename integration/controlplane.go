@@ -0,0 +1,254 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"sigs.k8s.io/testing_frameworks/cluster"
+	"sigs.k8s.io/testing_frameworks/cluster/type/base"
+	"sigs.k8s.io/testing_frameworks/integration/binaries"
+	"sigs.k8s.io/testing_frameworks/integration/internal"
+	"sigs.k8s.io/yaml"
+)
+
+// ControlPlane is a cluster.Fixture that composes an Etcd and an APIServer
+// into a single, ready-to-use test control plane, and hands back a KubeCtl
+// wired up to talk to it. It closes the gap between assembling Etcd and
+// APIServer by hand and having something you can Setup/TearDown in one
+// call:
+//
+//	cp := &integration.ControlPlane{}
+//	cp.Setup(cfg)
+//	kc := cp.KubeCtl()
+//	kc.Run("get", "pods")
+type ControlPlane struct {
+	Etcd      *Etcd
+	APIServer *APIServer
+
+	// KubeCtlPath is the path to the kubectl binary used by KubeCtl().
+	//
+	// If this is left as the empty string, we will attempt to locate a
+	// binary, by checking for the TEST_ASSET_KUBECTL environment
+	// variable, and the default test assets directory.
+	KubeCtlPath string
+}
+
+// Setup starts etcd, waits for it to be ready, then starts the apiserver
+// pointed at it and polls /healthz before returning.
+//
+// If config.AutoFetch is set, any of Etcd.Path, APIServer.Path, and
+// KubeCtlPath left unset are resolved by downloading config.KubernetesVersion
+// via binaries.Fetch before anything is started.
+func (c *ControlPlane) Setup(config cluster.Config) error {
+	if c.Etcd == nil {
+		c.Etcd = &Etcd{}
+	}
+	if c.APIServer == nil {
+		c.APIServer = &APIServer{}
+	}
+
+	if config.AutoFetch {
+		if err := c.autoFetch(config.KubernetesVersion); err != nil {
+			return fmt.Errorf("unable to auto-fetch binaries: %v", err)
+		}
+	}
+
+	c.Etcd.ClusterConfig = config
+
+	if err := c.Etcd.Start(); err != nil {
+		return fmt.Errorf("unable to start etcd: %v", err)
+	}
+
+	c.APIServer.ClusterConfig = config
+	c.APIServer.EtcdURL = c.Etcd.URL
+	c.APIServer.EtcdTLSConfig = c.Etcd.TLSConfig
+
+	if err := c.APIServer.Start(); err != nil {
+		c.Etcd.Stop()
+		return fmt.Errorf("unable to start apiserver: %v", err)
+	}
+
+	if err := c.waitForHealthz(); err != nil {
+		c.TearDown()
+		return fmt.Errorf("apiserver never became healthy: %v", err)
+	}
+
+	return nil
+}
+
+// autoFetch downloads version via binaries.Fetch and fills in Etcd.Path,
+// APIServer.Path, and KubeCtlPath wherever they are still unset.
+func (c *ControlPlane) autoFetch(version string) error {
+	assets, err := binaries.Fetch(context.Background(), version, binaries.Options{})
+	if err != nil {
+		return err
+	}
+
+	if c.Etcd.Path == "" {
+		c.Etcd.Path = assets.EtcdPath
+	}
+	if c.APIServer.Path == "" {
+		c.APIServer.Path = assets.KubeAPIServerPath
+	}
+	if c.KubeCtlPath == "" {
+		c.KubeCtlPath = assets.KubectlPath
+	}
+
+	return nil
+}
+
+// TearDown stops the apiserver and etcd, in that order, cleaning up their
+// data directories even if one of the two fails to stop.
+func (c *ControlPlane) TearDown() error {
+	var apiErr, etcdErr error
+
+	if c.APIServer != nil {
+		apiErr = c.APIServer.Stop()
+	}
+	if c.Etcd != nil {
+		etcdErr = c.Etcd.Stop()
+	}
+
+	if apiErr != nil {
+		return apiErr
+	}
+	return etcdErr
+}
+
+// ClientConfig returns the configuration a client needs to talk to this
+// ControlPlane's apiserver, in a form that can be serialized as a
+// kubeconfig file.
+func (c *ControlPlane) ClientConfig() base.Config {
+	const (
+		clusterName = "control-plane"
+		userName    = "control-plane-admin"
+		contextName = "control-plane"
+	)
+
+	// NOTE: c.APIServer.EtcdTLSConfig is the CA securing the apiserver's
+	// connection to etcd, not the CA that signed the apiserver's own
+	// serving certificate — those are separate trust chains, and this
+	// series never generates the latter. Leave CertificateAuthorityData
+	// unset until a client can be handed a CA that actually verifies the
+	// apiserver's certificate.
+	clusterEntry := &base.Cluster{
+		Server: c.APIServer.URL.String(),
+	}
+
+	return base.Config{
+		Clusters: map[string]*base.Cluster{
+			clusterName: clusterEntry,
+		},
+		AuthInfos: map[string]*base.AuthInfo{
+			userName: {},
+		},
+		Contexts: map[string]*base.Context{
+			contextName: {
+				Cluster:  clusterName,
+				AuthInfo: userName,
+			},
+		},
+		CurrentContext: contextName,
+	}
+}
+
+// waitForHealthz polls the apiserver's /healthz endpoint until it reports
+// ok, or the apiserver's StartTimeout elapses.
+func (c *ControlPlane) waitForHealthz() error {
+	deadline := time.Now().Add(c.APIServer.StartTimeout)
+
+	healthzURL := *c.APIServer.URL
+	healthzURL.Path = "/healthz"
+
+	var lastErr error
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(healthzURL.String())
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			lastErr = fmt.Errorf("unexpected status %s", resp.Status)
+		} else {
+			lastErr = err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return lastErr
+}
+
+// KubeCtl returns a KubeCtl pre-configured to talk to this ControlPlane's
+// apiserver.
+func (c *ControlPlane) KubeCtl() *KubeCtl {
+	return &KubeCtl{
+		Path:         internal.BinPathFinder("kubectl", c.KubeCtlPath),
+		ServerURL:    c.APIServer.URL.String(),
+		ClientConfig: c.ClientConfig(),
+	}
+}
+
+// KubeCtl knows how to shell out to the kubectl binary against a
+// particular apiserver.
+type KubeCtl struct {
+	// Path is the path to the kubectl binary to run.
+	Path string
+
+	// ServerURL is passed to every invocation via --server.
+	ServerURL string
+
+	// ClientConfig is serialized to a temporary kubeconfig file and
+	// passed to every invocation via --kubeconfig.
+	ClientConfig base.Config
+}
+
+// Run shells out to kubectl with the given args, plus --server and
+// --kubeconfig pre-populated, and returns its stdout and stderr.
+func (k *KubeCtl) Run(args ...string) (stdout, stderr io.Reader, err error) {
+	kubeconfigPath, err := writeKubeconfig(k.ClientConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to write kubeconfig: %v", err)
+	}
+	defer os.Remove(kubeconfigPath)
+
+	fullArgs := append([]string{
+		"--server=" + k.ServerURL,
+		"--kubeconfig=" + kubeconfigPath,
+	}, args...)
+
+	cmd := exec.Command(k.Path, fullArgs...)
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	err = cmd.Run()
+	return &outBuf, &errBuf, err
+}
+
+// writeKubeconfig serializes config to a temporary kubeconfig file and
+// returns its path.
+func writeKubeconfig(config base.Config) (string, error) {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := ioutil.TempFile("", "kubeconfig")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestControlPlaneClientConfig(t *testing.T) {
+	apiServerURL, err := url.Parse("https://127.0.0.1:6443")
+	if err != nil {
+		t.Fatalf("unable to parse apiserver URL: %v", err)
+	}
+
+	c := &ControlPlane{
+		APIServer: &APIServer{URL: apiServerURL},
+	}
+
+	cfg := c.ClientConfig()
+
+	cluster, ok := cfg.Clusters["control-plane"]
+	if !ok {
+		t.Fatal("ClientConfig() has no \"control-plane\" cluster entry")
+	}
+	if cluster.Server != apiServerURL.String() {
+		t.Errorf("cluster.Server = %q, want %q", cluster.Server, apiServerURL.String())
+	}
+	if len(cluster.CertificateAuthorityData) != 0 {
+		t.Errorf("cluster.CertificateAuthorityData = %q, want empty: the etcd CA does not verify the apiserver's certificate", cluster.CertificateAuthorityData)
+	}
+}
+
+func TestKubeCtlRunRemovesKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	markerPath := filepath.Join(dir, "kubeconfig-path")
+	fakeKubectl := writeFakeKubectl(t, dir, markerPath)
+
+	kc := &KubeCtl{
+		Path:      fakeKubectl,
+		ServerURL: "https://127.0.0.1:6443",
+	}
+
+	if _, _, err := kc.Run("get", "pods"); err != nil {
+		t.Fatalf("Run() returned an error: %v", err)
+	}
+
+	data, err := os.ReadFile(markerPath)
+	if err != nil {
+		t.Fatalf("fake kubectl never observed a --kubeconfig flag: %v", err)
+	}
+	seenPath := trimNewline(string(data))
+
+	if _, err := os.Stat(seenPath); !os.IsNotExist(err) {
+		t.Errorf("kubeconfig %s still exists after Run() returned, want it removed", seenPath)
+	}
+}
+
+// writeFakeKubectl writes an executable shell script standing in for
+// kubectl that records the path passed via --kubeconfig into markerPath
+// while the file still exists, then returns its path.
+func writeFakeKubectl(t *testing.T, dir, markerPath string) string {
+	t.Helper()
+
+	script := filepath.Join(dir, "kubectl")
+
+	body := `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in
+    --kubeconfig=*) echo "${arg#--kubeconfig=}" > "` + markerPath + `" ;;
+  esac
+done
+exit 0
+`
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("unable to write fake kubectl: %v", err)
+	}
+
+	return script
+}
+
+func trimNewline(s string) string {
+	for len(s) > 0 && (s[len(s)-1] == '\n' || s[len(s)-1] == '\r') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
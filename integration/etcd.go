@@ -1,11 +1,18 @@
 package integration
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"time"
 
+	"net"
 	"net/url"
 
+	clientv3 "go.etcd.io/etcd/client/v3"
+
 	"sigs.k8s.io/testing_frameworks/cluster"
 	"sigs.k8s.io/testing_frameworks/integration/internal"
 )
@@ -34,6 +41,18 @@ type Etcd struct {
 	// - ClusterConfig.Etcd.DataDir
 	ClusterConfig cluster.Config
 
+	// TLSConfig, if set, causes this Etcd to serve and dial over mTLS
+	// instead of plain HTTP. Any PEM bundle left unset is generated on
+	// the fly by a self-signed CA, so the zero value enables TLS with
+	// sane defaults.
+	TLSConfig *TLSConfig
+
+	// EnableFailpoints, if true, sets the GOFAIL_HTTP environment variable
+	// on the etcd process so that failpoints can be triggered through
+	// Failpoints(). The etcd binary at Path must be built with
+	// FAILPOINTS=true for this to have any effect.
+	EnableFailpoints bool
+
 	// StartTimeout, StopTimeout specify the time the Etcd is allowed to
 	// take when starting and stopping before an error is emitted.
 	//
@@ -47,7 +66,12 @@ type Etcd struct {
 	Out io.Writer
 	Err io.Writer
 
-	processState *internal.ProcessState
+	processState  *internal.ProcessState
+	failpointAddr string
+
+	client           *clientv3.Client
+	generation       int
+	clientGeneration int
 }
 
 // Start starts the etcd, waits for it to come up, and returns an error, if one
@@ -55,6 +79,7 @@ type Etcd struct {
 func (e *Etcd) Start() error {
 	var err error
 
+	e.generation++
 	e.processState = &internal.ProcessState{}
 
 	e.processState.DefaultedProcessInput, err = internal.DoDefaulting(
@@ -76,6 +101,17 @@ func (e *Etcd) Start() error {
 	e.StartTimeout = e.processState.StartTimeout
 	e.StopTimeout = e.processState.StopTimeout
 
+	extraArgs := e.ClusterConfig.Etcd.ExtraArgs
+
+	if e.TLSConfig != nil {
+		tlsArgs, err := e.writeTLSAssetsAndArgs()
+		if err != nil {
+			return fmt.Errorf("unable to set up TLS for etcd: %v", err)
+		}
+		e.URL.Scheme = "https"
+		extraArgs = append(append([]string{}, extraArgs...), tlsArgs...)
+	}
+
 	tmplData := struct {
 		URL     *url.URL
 		DataDir string
@@ -84,7 +120,7 @@ func (e *Etcd) Start() error {
 		e.processState.Dir,
 	}
 
-	args := flattenArgs(e.ClusterConfig.Etcd.ExtraArgs)
+	args := flattenArgs(extraArgs)
 
 	e.processState.Args, err = internal.RenderTemplates(
 		internal.DoEtcdArgDefaulting(args), tmplData,
@@ -93,6 +129,19 @@ func (e *Etcd) Start() error {
 		return err
 	}
 
+	if e.EnableFailpoints {
+		failpointURL, err := freeURL()
+		if err != nil {
+			return fmt.Errorf("unable to allocate a GOFAIL_HTTP address: %v", err)
+		}
+		e.failpointAddr = failpointURL.Host
+
+		// Set GOFAIL_HTTP only in this process's environment, not the test
+		// binary's. os.Setenv would race with, and leak into, any other
+		// Etcd/EtcdCluster starting concurrently in the same binary.
+		e.processState.Env = append(os.Environ(), "GOFAIL_HTTP="+e.failpointAddr)
+	}
+
 	return e.processState.Start(e.Out, e.Err)
 }
 
@@ -102,9 +151,63 @@ func (e *Etcd) Stop() error {
 	return e.processState.Stop()
 }
 
+// writeTLSAssetsAndArgs generates any unset TLS PEM bundles on e.TLSConfig,
+// writes them to files under the etcd data directory, and returns the etcd
+// flags needed to serve and peer over mTLS using those files.
+func (e *Etcd) writeTLSAssetsAndArgs() ([]string, error) {
+	if err := e.TLSConfig.GenerateFor("localhost", "127.0.0.1"); err != nil {
+		return nil, err
+	}
+
+	certsDir := filepath.Join(e.processState.Dir, "certs")
+	if err := os.MkdirAll(certsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{
+		"ca.crt":     e.TLSConfig.CACert,
+		"server.crt": e.TLSConfig.ServerCert,
+		"server.key": e.TLSConfig.ServerKey,
+		"peer.crt":   e.TLSConfig.PeerCert,
+		"peer.key":   e.TLSConfig.PeerKey,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(certsDir, name), contents, 0600); err != nil {
+			return nil, fmt.Errorf("unable to write %s: %v", name, err)
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("--cert-file=%s", filepath.Join(certsDir, "server.crt")),
+		fmt.Sprintf("--key-file=%s", filepath.Join(certsDir, "server.key")),
+		fmt.Sprintf("--trusted-ca-file=%s", filepath.Join(certsDir, "ca.crt")),
+		fmt.Sprintf("--peer-cert-file=%s", filepath.Join(certsDir, "peer.crt")),
+		fmt.Sprintf("--peer-key-file=%s", filepath.Join(certsDir, "peer.key")),
+		fmt.Sprintf("--peer-trusted-ca-file=%s", filepath.Join(certsDir, "ca.crt")),
+	}, nil
+}
+
 // EtcdDefaultArgs exposes the default args for Etcd so that you
 // can use those to append your own additional arguments.
 //
 // The internal default arguments are explicitely copied here, we don't want to
 // allow users to change the internal ones.
 var EtcdDefaultArgs = append([]string{}, internal.EtcdDefaultArgs...)
+
+// freeURL returns a URL pointing at a free port on localhost, suitable for
+// the GOFAIL_HTTP failpoint listen address. The port is not held open, so
+// there is a small window in which another process could steal it; peer
+// and client URLs don't have this problem since they're allocated through
+// internal.SuggestAddress instead.
+func freeURL() (*url.URL, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	return &url.URL{
+		Scheme: "http",
+		Host:   l.Addr().String(),
+	}, nil
+}
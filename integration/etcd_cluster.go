@@ -0,0 +1,439 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"sigs.k8s.io/testing_frameworks/cluster"
+	"sigs.k8s.io/testing_frameworks/integration/internal"
+)
+
+// EtcdCluster knows how to run a clustered etcd, made up of one or more
+// member processes that each talk the etcd peer protocol to one another.
+//
+// Where Etcd brings up a single standalone process, EtcdCluster brings up
+// cluster.Config.Shape.NodeCount members wired together via
+// --initial-cluster, so tests can exercise partitions, quorum loss, and
+// member reconfiguration.
+type EtcdCluster struct {
+	// ClusterConfig is the kubeadm-compatible configuration shared by all
+	// members of the cluster. ClusterConfig.Shape.NodeCount controls how
+	// many members are started; if zero, a single member is started.
+	ClusterConfig cluster.Config
+
+	// Path is the path to the etcd binary used for every member.
+	//
+	// If this is left as the empty string, we will attempt to locate a
+	// binary the same way Etcd does.
+	Path string
+
+	// StartTimeout, StopTimeout specify the time each member is allowed to
+	// take when starting and stopping before an error is emitted.
+	//
+	// If not specified, these default to 20 seconds.
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+
+	// Out, Err specify where every member should write its StdOut, StdErr
+	// to.
+	//
+	// If not specified, the output will be discarded.
+	Out io.Writer
+	Err io.Writer
+
+	// EtcdctlPath is the path to the etcdctl binary used by
+	// SetClusterDowngradeTarget. ClusterVersion does not use it; it reads
+	// the negotiated cluster version directly off a member's /version
+	// endpoint instead of shelling out.
+	//
+	// If this is left as the empty string, we fall back to "etcdctl" on
+	// $PATH.
+	EtcdctlPath string
+
+	members []*etcdClusterMember
+}
+
+// etcdClusterMember is a single node of an EtcdCluster.
+type etcdClusterMember struct {
+	Name    string
+	PeerURL *url.URL
+	Etcd    *Etcd
+	stopped bool
+	removed bool
+}
+
+// UpgradeMember stops member i, points it at the etcd binary found at
+// newPath, and restarts it against its existing data directory and peer
+// URL, waiting for it to rejoin the cluster.
+func (c *EtcdCluster) UpgradeMember(i int, newPath string) error {
+	return c.swapMemberBinary(i, newPath)
+}
+
+// DowngradeMember stops member i, points it at the etcd binary found at
+// newPath, and restarts it against its existing data directory and peer
+// URL, waiting for it to rejoin the cluster.
+//
+// This is the same operation as UpgradeMember; it is provided separately
+// so that tests staging a downgrade read clearly at the call site.
+func (c *EtcdCluster) DowngradeMember(i int, newPath string) error {
+	return c.swapMemberBinary(i, newPath)
+}
+
+func (c *EtcdCluster) swapMemberBinary(i int, newPath string) error {
+	m, err := c.member(i)
+	if err != nil {
+		return err
+	}
+
+	if !m.stopped {
+		if err := m.Etcd.Stop(); err != nil {
+			return fmt.Errorf("unable to stop member %s: %v", m.Name, err)
+		}
+	}
+
+	m.Etcd.Path = newPath
+	if err := m.Etcd.Start(); err != nil {
+		return fmt.Errorf("unable to restart member %s on %s: %v", m.Name, newPath, err)
+	}
+	m.stopped = false
+	return nil
+}
+
+// ClusterVersion reports the negotiated cluster version: the storage
+// schema version the whole cluster has agreed it is safe to use, as
+// opposed to any single member's own etcdserver binary version. This is
+// the field that matters for staging mixed-version clusters and
+// SetClusterDowngradeTarget, so it is read from the "etcdcluster" field of
+// a running member's /version endpoint rather than from
+// `etcdctl endpoint status`, whose "version" field only reports that one
+// member's binary version.
+func (c *EtcdCluster) ClusterVersion() (string, error) {
+	alive := c.anyRunningMemberExcept(nil)
+	if alive == nil {
+		return "", fmt.Errorf("no running members to query")
+	}
+
+	resp, err := http.Get(alive.Etcd.URL.String() + "/version")
+	if err != nil {
+		return "", fmt.Errorf("unable to query /version: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		Cluster string `json:"etcdcluster"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("unable to parse /version response: %v", err)
+	}
+
+	return version.Cluster, nil
+}
+
+// SetClusterDowngradeTarget issues `etcdctl downgrade enable <version>`
+// against a running member, asking the cluster to begin downgrading to
+// the given version.
+func (c *EtcdCluster) SetClusterDowngradeTarget(version string) error {
+	alive := c.anyRunningMemberExcept(nil)
+	if alive == nil {
+		return fmt.Errorf("no running members to issue the downgrade against")
+	}
+
+	_, err := c.etcdctl("--endpoints="+alive.Etcd.URL.String(), "downgrade", "enable", version)
+	return err
+}
+
+func (c *EtcdCluster) etcdctl(args ...string) ([]byte, error) {
+	path := c.EtcdctlPath
+	if path == "" {
+		path = "etcdctl"
+	}
+	return exec.Command(path, args...).Output()
+}
+
+// Start brings up every member of the cluster and blocks until all of them
+// have reported that they are ready to serve client requests.
+func (c *EtcdCluster) Start() error {
+	count := c.ClusterConfig.Shape.NodeCount
+	if count <= 0 {
+		count = 1
+	}
+
+	members := make([]*etcdClusterMember, count)
+	for i := range members {
+		peerURL, err := internal.SuggestAddress()
+		if err != nil {
+			return fmt.Errorf("unable to allocate a peer URL for member %d: %v", i, err)
+		}
+		members[i] = &etcdClusterMember{
+			Name:    fmt.Sprintf("etcd-%d", i),
+			PeerURL: peerURL,
+		}
+	}
+
+	initialCluster := initialClusterString(members)
+
+	for i, m := range members {
+		clusterConfig := c.ClusterConfig
+		clusterConfig.Etcd.DataDir = memberDataDir(c.ClusterConfig.Etcd.DataDir, i)
+		clusterConfig.Etcd.ExtraArgs = append(append([]string{}, c.ClusterConfig.Etcd.ExtraArgs...),
+			fmt.Sprintf("--name=%s", m.Name),
+			fmt.Sprintf("--initial-advertise-peer-urls=%s", m.PeerURL.String()),
+			fmt.Sprintf("--listen-peer-urls=%s", m.PeerURL.String()),
+			fmt.Sprintf("--initial-cluster=%s", initialCluster),
+			"--initial-cluster-state=new",
+		)
+
+		m.Etcd = &Etcd{
+			Path:          c.Path,
+			ClusterConfig: clusterConfig,
+			StartTimeout:  c.StartTimeout,
+			StopTimeout:   c.StopTimeout,
+			Out:           c.Out,
+			Err:           c.Err,
+		}
+
+		if err := m.Etcd.Start(); err != nil {
+			return fmt.Errorf("unable to start member %s: %v", m.Name, err)
+		}
+	}
+
+	c.members = members
+	return nil
+}
+
+// Stop stops every member of the cluster, in member order, making a
+// best-effort attempt at each one even if an earlier member fails to
+// stop, so that a single stuck member doesn't leak the rest of the
+// cluster's processes.
+func (c *EtcdCluster) Stop() error {
+	var errs []string
+	for _, m := range c.members {
+		if m.removed || m.stopped {
+			continue
+		}
+		if err := m.Etcd.Stop(); err != nil {
+			errs = append(errs, fmt.Sprintf("member %s: %v", m.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to stop every member: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// URL returns the full comma-separated list of client URLs of every member
+// that is currently running.
+func (c *EtcdCluster) URL() string {
+	urls := make([]string, 0, len(c.members))
+	for _, m := range c.members {
+		if m.removed || m.stopped {
+			continue
+		}
+		urls = append(urls, m.Etcd.URL.String())
+	}
+	return strings.Join(urls, ",")
+}
+
+// StopMember stops the i'th member without removing it from the cluster
+// membership, so that it can later be restarted with StartMember.
+func (c *EtcdCluster) StopMember(i int) error {
+	m, err := c.member(i)
+	if err != nil {
+		return err
+	}
+	if err := m.Etcd.Stop(); err != nil {
+		return err
+	}
+	m.stopped = true
+	return nil
+}
+
+// StartMember restarts the i'th member against its existing data
+// directory and peer URL.
+func (c *EtcdCluster) StartMember(i int) error {
+	m, err := c.member(i)
+	if err != nil {
+		return err
+	}
+	if err := m.Etcd.Start(); err != nil {
+		return err
+	}
+	m.stopped = false
+	return nil
+}
+
+// AddMember announces a new member's peer URL to the existing cluster via
+// a MemberAdd raft configuration change, then starts it and joins it to
+// the existing cluster, returning its index within the cluster. The
+// existing members must already be up, since the new member is both
+// announced through, and started with --initial-cluster-state=existing
+// against, one of them.
+func (c *EtcdCluster) AddMember() (int, error) {
+	peerURL, err := internal.SuggestAddress()
+	if err != nil {
+		return 0, fmt.Errorf("unable to allocate a peer URL for the new member: %v", err)
+	}
+
+	i := len(c.members)
+	m := &etcdClusterMember{
+		Name:    fmt.Sprintf("etcd-%d", i),
+		PeerURL: peerURL,
+	}
+
+	nonRemoved := c.runningMembers()
+
+	alive := c.anyRunningMemberExcept(nil)
+	if alive == nil {
+		return 0, fmt.Errorf("no running members to join %s through", m.Name)
+	}
+
+	client, err := alive.Etcd.Client(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("unable to dial existing cluster: %v", err)
+	}
+
+	addResp, err := client.MemberAdd(context.Background(), []string{peerURL.String()})
+	if err != nil {
+		return 0, fmt.Errorf("unable to announce new member %s to the cluster: %v", m.Name, err)
+	}
+
+	initialCluster := initialClusterString(append(nonRemoved, m))
+
+	clusterConfig := c.ClusterConfig
+	clusterConfig.Etcd.DataDir = memberDataDir(c.ClusterConfig.Etcd.DataDir, i)
+	clusterConfig.Etcd.ExtraArgs = append(append([]string{}, c.ClusterConfig.Etcd.ExtraArgs...),
+		fmt.Sprintf("--name=%s", m.Name),
+		fmt.Sprintf("--initial-advertise-peer-urls=%s", m.PeerURL.String()),
+		fmt.Sprintf("--listen-peer-urls=%s", m.PeerURL.String()),
+		fmt.Sprintf("--initial-cluster=%s", initialCluster),
+		"--initial-cluster-state=existing",
+	)
+
+	m.Etcd = &Etcd{
+		Path:          c.Path,
+		ClusterConfig: clusterConfig,
+		StartTimeout:  c.StartTimeout,
+		StopTimeout:   c.StopTimeout,
+		Out:           c.Out,
+		Err:           c.Err,
+	}
+
+	if err := m.Etcd.Start(); err != nil {
+		// The cluster's raft configuration already expects this member as
+		// of MemberAdd above; if it never actually starts, undo that so
+		// the rest of the cluster doesn't wait on a voter that will never
+		// show up.
+		if _, removeErr := client.MemberRemove(context.Background(), addResp.Member.ID); removeErr != nil {
+			return 0, fmt.Errorf("unable to start new member %s: %v (also failed to retract its membership: %v)", m.Name, err, removeErr)
+		}
+		return 0, fmt.Errorf("unable to start new member %s: %v", m.Name, err)
+	}
+
+	c.members = append(c.members, m)
+	return i, nil
+}
+
+// RemoveMember stops the member with the given index, then — if any other
+// member is still up — issues a MemberRemove raft configuration change so
+// the rest of the cluster stops expecting it and quorum math adjusts
+// accordingly.
+func (c *EtcdCluster) RemoveMember(id int) error {
+	m, err := c.member(id)
+	if err != nil {
+		return err
+	}
+
+	if !m.stopped {
+		if err := m.Etcd.Stop(); err != nil {
+			return fmt.Errorf("unable to stop member %s: %v", m.Name, err)
+		}
+		m.stopped = true
+	}
+
+	if other := c.anyRunningMemberExcept(m); other != nil {
+		client, err := other.Etcd.Client(context.Background())
+		if err != nil {
+			return fmt.Errorf("unable to dial cluster to announce removal of %s: %v", m.Name, err)
+		}
+
+		memberID, err := lookupMemberID(client, m.Name)
+		if err != nil {
+			return err
+		}
+
+		if _, err := client.MemberRemove(context.Background(), memberID); err != nil {
+			return fmt.Errorf("unable to remove member %s from the cluster: %v", m.Name, err)
+		}
+	}
+
+	m.removed = true
+	return nil
+}
+
+func (c *EtcdCluster) member(i int) (*etcdClusterMember, error) {
+	if i < 0 || i >= len(c.members) || c.members[i].removed {
+		return nil, fmt.Errorf("no such member: %d", i)
+	}
+	return c.members[i], nil
+}
+
+func (c *EtcdCluster) runningMembers() []*etcdClusterMember {
+	running := make([]*etcdClusterMember, 0, len(c.members))
+	for _, m := range c.members {
+		if !m.removed {
+			running = append(running, m)
+		}
+	}
+	return running
+}
+
+// anyRunningMemberExcept returns a member other than exclude that is
+// neither stopped nor removed, or nil if there is none.
+func (c *EtcdCluster) anyRunningMemberExcept(exclude *etcdClusterMember) *etcdClusterMember {
+	for _, m := range c.members {
+		if m == exclude || m.removed || m.stopped {
+			continue
+		}
+		return m
+	}
+	return nil
+}
+
+// lookupMemberID finds the cluster member ID matching name, by asking
+// client's target member for the full membership list.
+func lookupMemberID(client *clientv3.Client, name string) (uint64, error) {
+	resp, err := client.MemberList(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("unable to list cluster members: %v", err)
+	}
+	for _, mem := range resp.Members {
+		if mem.Name == name {
+			return mem.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("member %s not found in cluster member list", name)
+}
+
+func initialClusterString(members []*etcdClusterMember) string {
+	parts := make([]string, len(members))
+	for i, m := range members {
+		parts[i] = fmt.Sprintf("%s=%s", m.Name, m.PeerURL.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func memberDataDir(base string, i int) string {
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", base, i)
+}
@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"net/url"
+	"testing"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("unable to parse %q: %v", raw, err)
+	}
+	return u
+}
+
+func TestInitialClusterString(t *testing.T) {
+	members := []*etcdClusterMember{
+		{Name: "etcd-0", PeerURL: mustParseURL(t, "http://127.0.0.1:1000")},
+		{Name: "etcd-1", PeerURL: mustParseURL(t, "http://127.0.0.1:1001")},
+	}
+
+	got := initialClusterString(members)
+	want := "etcd-0=http://127.0.0.1:1000,etcd-1=http://127.0.0.1:1001"
+	if got != want {
+		t.Errorf("initialClusterString() = %q, want %q", got, want)
+	}
+}
+
+func TestMemberDataDir(t *testing.T) {
+	if got := memberDataDir("", 0); got != "" {
+		t.Errorf("memberDataDir(\"\", 0) = %q, want empty", got)
+	}
+	if got, want := memberDataDir("/tmp/etcd-data", 2), "/tmp/etcd-data-2"; got != want {
+		t.Errorf("memberDataDir() = %q, want %q", got, want)
+	}
+}
+
+func TestEtcdClusterMember(t *testing.T) {
+	c := &EtcdCluster{
+		members: []*etcdClusterMember{
+			{Name: "etcd-0"},
+			{Name: "etcd-1", removed: true},
+		},
+	}
+
+	if _, err := c.member(1); err == nil {
+		t.Error("member(1) on a removed member: got nil error, want an error")
+	}
+	if _, err := c.member(5); err == nil {
+		t.Error("member(5) out of range: got nil error, want an error")
+	}
+
+	m, err := c.member(0)
+	if err != nil {
+		t.Fatalf("member(0): unexpected error: %v", err)
+	}
+	if m.Name != "etcd-0" {
+		t.Errorf("member(0).Name = %q, want %q", m.Name, "etcd-0")
+	}
+}
+
+func TestEtcdClusterRunningMembers(t *testing.T) {
+	c := &EtcdCluster{
+		members: []*etcdClusterMember{
+			{Name: "etcd-0"},
+			{Name: "etcd-1", removed: true},
+			{Name: "etcd-2", stopped: true},
+		},
+	}
+
+	running := c.runningMembers()
+	if len(running) != 2 {
+		t.Fatalf("runningMembers() returned %d members, want 2 (stopped members still count as cluster members)", len(running))
+	}
+	if running[0].Name != "etcd-0" || running[1].Name != "etcd-2" {
+		t.Errorf("runningMembers() = %v, want [etcd-0 etcd-2]", running)
+	}
+}
+
+func TestEtcdClusterAnyRunningMemberExcept(t *testing.T) {
+	m0 := &etcdClusterMember{Name: "etcd-0"}
+	m1 := &etcdClusterMember{Name: "etcd-1", stopped: true}
+	m2 := &etcdClusterMember{Name: "etcd-2", removed: true}
+	c := &EtcdCluster{members: []*etcdClusterMember{m0, m1, m2}}
+
+	if got := c.anyRunningMemberExcept(nil); got != m0 {
+		t.Errorf("anyRunningMemberExcept(nil) = %v, want %v", got, m0)
+	}
+	if got := c.anyRunningMemberExcept(m0); got != nil {
+		t.Errorf("anyRunningMemberExcept(m0) = %v, want nil (m1 is stopped, m2 is removed)", got)
+	}
+}
+
+func TestEtcdClusterURL(t *testing.T) {
+	c := &EtcdCluster{
+		members: []*etcdClusterMember{
+			{Name: "etcd-0", Etcd: &Etcd{URL: mustParseURL(t, "http://127.0.0.1:1000")}},
+			{Name: "etcd-1", Etcd: &Etcd{URL: mustParseURL(t, "http://127.0.0.1:1001")}, stopped: true},
+			{Name: "etcd-2", Etcd: &Etcd{URL: mustParseURL(t, "http://127.0.0.1:1002")}},
+		},
+	}
+
+	if got, want := c.URL(), "http://127.0.0.1:1000,http://127.0.0.1:1002"; got != want {
+		t.Errorf("URL() = %q, want %q (stopped members should be excluded)", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// FailpointClient talks to a gofail-instrumented etcd binary's failpoint
+// HTTP endpoint, letting tests trigger deterministic failure paths (write-
+// ahead-log crashes, snapshot corruption, slow disks, ...) without patching
+// etcd itself.
+//
+// The target binary must be built with FAILPOINTS=true; see
+// https://github.com/etcd-io/gofail for the underlying protocol.
+type FailpointClient interface {
+	// Activate enables the named failpoint with the given gofail term,
+	// e.g. "panic" or `sleep("1s")`.
+	Activate(name, term string) error
+
+	// Deactivate disables the named failpoint.
+	Deactivate(name string) error
+
+	// List returns every failpoint compiled into the binary, along with
+	// its currently active term (the empty string if inactive).
+	List() (map[string]string, error)
+}
+
+// failpointClient is the default FailpointClient, talking to the
+// GOFAIL_HTTP endpoint of a single etcd process.
+type failpointClient struct {
+	addr string
+}
+
+// Failpoints returns a FailpointClient for this Etcd, talking to the
+// GOFAIL_HTTP endpoint configured for it by EnableFailpoints.
+//
+// It is only usable once the Etcd has been started with EnableFailpoints
+// set to true.
+func (e *Etcd) Failpoints() FailpointClient {
+	return &failpointClient{addr: e.failpointAddr}
+}
+
+func (f *failpointClient) url(name string) string {
+	return fmt.Sprintf("http://%s/%s", f.addr, name)
+}
+
+func (f *failpointClient) Activate(name, term string) error {
+	req, err := http.NewRequest(http.MethodPut, f.url(name), bytes.NewBufferString(term))
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to activate failpoint %q: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to activate failpoint %q: unexpected status %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (f *failpointClient) Deactivate(name string) error {
+	req, err := http.NewRequest(http.MethodDelete, f.url(name), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to deactivate failpoint %q: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to deactivate failpoint %q: unexpected status %s", name, resp.Status)
+	}
+	return nil
+}
+
+func (f *failpointClient) List() (map[string]string, error) {
+	resp, err := http.Get(f.url(""))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list failpoints: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to list failpoints: unexpected status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFailpointList(body), nil
+}
+
+// parseFailpointList parses the gofail HTTP listing format, one
+// "name=term" pair per line.
+func parseFailpointList(body []byte) map[string]string {
+	points := map[string]string{}
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		parts := bytes.SplitN(line, []byte("="), 2)
+		if len(parts) != 2 {
+			continue
+		}
+		points[string(parts[0])] = string(parts[1])
+	}
+	return points
+}
@@ -0,0 +1,215 @@
+package integration
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TLSConfig configures an etcd (or apiserver) process to serve and dial
+// over mTLS.
+//
+// A caller may provide their own CA/cert/key PEM bundles, or leave them
+// unset and call GenerateFor to have a self-signed CA and a matching
+// server, peer, and client certificate generated on the fly.
+type TLSConfig struct {
+	// CACert, CAKey are the PEM-encoded CA certificate and key used to
+	// sign the server, peer, and client certificates below.
+	CACert []byte
+	CAKey  []byte
+
+	// ServerCert, ServerKey are the PEM-encoded certificate and key the
+	// process should serve client connections with.
+	ServerCert []byte
+	ServerKey  []byte
+
+	// PeerCert, PeerKey are the PEM-encoded certificate and key the
+	// process should use for peer (member-to-member) connections. Unused
+	// by single-process fixtures such as a standalone APIServer.
+	PeerCert []byte
+	PeerKey  []byte
+
+	// ClientCert, ClientKey are the PEM-encoded certificate and key a
+	// client dialing this process should present.
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+// GenerateFor fills in any PEM bundles left unset on t with a freshly
+// generated, self-signed CA and certificates valid for the given hosts
+// (typically "localhost" and/or a loopback IP). It is a no-op for any
+// bundle the caller has already populated, so a caller may bring their own
+// CA and let the rest be derived from it.
+func (t *TLSConfig) GenerateFor(hosts ...string) error {
+	if t.CACert == nil || t.CAKey == nil {
+		caCert, caKey, err := generateCA()
+		if err != nil {
+			return fmt.Errorf("unable to generate CA: %v", err)
+		}
+		t.CACert, t.CAKey = caCert, caKey
+	}
+
+	if t.ServerCert == nil || t.ServerKey == nil {
+		cert, key, err := generateSignedCert(t.CACert, t.CAKey, hosts, x509.ExtKeyUsageServerAuth)
+		if err != nil {
+			return fmt.Errorf("unable to generate server certificate: %v", err)
+		}
+		t.ServerCert, t.ServerKey = cert, key
+	}
+
+	if t.PeerCert == nil || t.PeerKey == nil {
+		cert, key, err := generateSignedCert(t.CACert, t.CAKey, hosts, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+		if err != nil {
+			return fmt.Errorf("unable to generate peer certificate: %v", err)
+		}
+		t.PeerCert, t.PeerKey = cert, key
+	}
+
+	if t.ClientCert == nil || t.ClientKey == nil {
+		cert, key, err := generateSignedCert(t.CACert, t.CAKey, hosts, x509.ExtKeyUsageClientAuth)
+		if err != nil {
+			return fmt.Errorf("unable to generate client certificate: %v", err)
+		}
+		t.ClientCert, t.ClientKey = cert, key
+	}
+
+	return nil
+}
+
+// writeEtcdClientTLSFiles writes tls's CA and client cert/key to dir and
+// returns the --etcd-cafile/--etcd-certfile/--etcd-keyfile flags pointing
+// at them.
+func writeEtcdClientTLSFiles(dir string, tls *TLSConfig) ([]string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	caFile := filepath.Join(dir, "ca.crt")
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+
+	for name, contents := range map[string][]byte{
+		caFile:   tls.CACert,
+		certFile: tls.ClientCert,
+		keyFile:  tls.ClientKey,
+	} {
+		if err := ioutil.WriteFile(name, contents, 0600); err != nil {
+			return nil, fmt.Errorf("unable to write %s: %v", name, err)
+		}
+	}
+
+	return []string{
+		"--etcd-cafile=" + caFile,
+		"--etcd-certfile=" + certFile,
+		"--etcd-keyfile=" + keyFile,
+	}, nil
+}
+
+func generateCA() (certPEM, keyPEM []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "testing-frameworks-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCert(der), encodeKey(key), nil
+}
+
+func generateSignedCert(caCertPEM, caKeyPEM []byte, hosts []string, usages ...x509.ExtKeyUsage) (certPEM, keyPEM []byte, err error) {
+	caCert, caKey, err := decodeCA(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "testing-frameworks"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  usages,
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCert(der), encodeKey(key), nil
+}
+
+func decodeCA(certPEM, keyPEM []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, nil, fmt.Errorf("unable to decode CA certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, nil, fmt.Errorf("unable to decode CA key PEM")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func encodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}